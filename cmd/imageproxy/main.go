@@ -0,0 +1,70 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// imageproxy starts an image proxy server.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"willnorris.com/go/imageproxy"
+	"willnorris.com/go/imageproxy/signature"
+)
+
+var (
+	addr             = flag.String("addr", "localhost:8080", "TCP address to listen on")
+	whitelist        = flag.String("whitelist", "", "comma separated list of allowed remote hosts")
+	referrers        = flag.String("referrers", "", "comma separated list of allowed referring hosts")
+	mappingURL       = flag.String("mappingurl", "", "URL or path to the route mapping JSON file")
+	exclusive        = flag.Bool("exclusive", false, "only allow requests matching the route mapping")
+	scaleUp          = flag.Bool("scaleUp", false, "allow images to scale beyond their original dimensions")
+	verbose          = flag.Bool("verbose", false, "print verbose log messages")
+	timeout          = flag.Duration("timeout", 0, "time limit for requests served by this proxy")
+	signatureKeyring = flag.String("signatureKeyring", "", "path to a file containing one or more HMAC signing keys, used to verify signed requests; signatures made by signature.Sign with an exp claim stop validating once it passes, signatures without one never expire")
+)
+
+func main() {
+	flag.Parse()
+
+	p := imageproxy.NewProxy(nil, nil, *exclusive, *mappingURL)
+	p.ScaleUp = *scaleUp
+	p.Verbose = *verbose
+	p.Timeout = *timeout
+
+	if *whitelist != "" {
+		p.Whitelist = strings.Split(*whitelist, ",")
+	}
+	if *referrers != "" {
+		p.Referrers = strings.Split(*referrers, ",")
+	}
+
+	if *signatureKeyring != "" {
+		keyring, err := signature.LoadKeyringFile(*signatureKeyring)
+		if err != nil {
+			log.Fatalf("error loading signature keyring: %v", err)
+		}
+		p.SignatureKeys = keyring
+	}
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: p,
+	}
+
+	log.Printf("imageproxy listening on %s", *addr)
+	log.Fatal(server.ListenAndServe())
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fetcher provides pluggable retrieval of source media from
+// non-HTTP backends, selected by URL scheme.
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Fetcher retrieves the content addressed by u, returning its body and any
+// headers relevant to serving it (at minimum, Content-Type).  Callers are
+// responsible for closing the returned ReadCloser.
+type Fetcher interface {
+	Fetch(ctx context.Context, u *url.URL) (io.ReadCloser, http.Header, error)
+}
+
+// Registry maps a URL scheme to the Fetcher responsible for it.
+type Registry struct {
+	fetchers map[string]Fetcher
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{fetchers: make(map[string]Fetcher)}
+}
+
+// Register adds f as the Fetcher for scheme, replacing any previous
+// registration.
+func (r *Registry) Register(scheme string, f Fetcher) {
+	r.fetchers[scheme] = f
+}
+
+// Get returns the Fetcher registered for scheme, if any.
+func (r *Registry) Get(scheme string) (Fetcher, bool) {
+	f, ok := r.fetchers[scheme]
+	return f, ok
+}
+
+// Default returns a Registry with the built-in s3://, gs://, and oci://
+// fetchers registered, each using its backend's default credential chain.
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register("s3", NewS3Fetcher())
+	r.Register("gs", NewGCSFetcher())
+	r.Register("oci", NewOCIFetcher())
+	return r
+}
+
+// errUnsupportedScheme is returned by fetchers when asked to fetch a URL
+// whose scheme they don't recognize, which should never happen given a
+// correctly configured Registry.
+func errUnsupportedScheme(scheme string) error {
+	return fmt.Errorf("fetcher: unsupported scheme %q", scheme)
+}
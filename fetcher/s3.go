@@ -0,0 +1,79 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Fetcher fetches objects from Amazon S3 (or an S3-compatible store),
+// addressed as s3://bucket/key.
+type S3Fetcher struct {
+	client *s3.Client
+}
+
+// NewS3Fetcher returns an S3Fetcher that authenticates using the default
+// AWS credential chain (environment, shared config, EC2/ECS role, etc.),
+// resolved lazily on first use so that constructing a Fetcher never fails
+// just because no AWS environment is configured.
+func NewS3Fetcher() *S3Fetcher {
+	return &S3Fetcher{}
+}
+
+func (f *S3Fetcher) getClient() (*s3.Client, error) {
+	if f.client != nil {
+		return f.client, nil
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("fetcher: loading AWS config: %v", err)
+	}
+	f.client = s3.NewFromConfig(cfg)
+	return f.client, nil
+}
+
+// Fetch implements Fetcher.
+func (f *S3Fetcher) Fetch(ctx context.Context, u *url.URL) (io.ReadCloser, http.Header, error) {
+	if u.Scheme != "s3" {
+		return nil, nil, errUnsupportedScheme(u.Scheme)
+	}
+
+	client, err := f.getClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetcher: s3 GetObject %s/%s: %v", bucket, key, err)
+	}
+
+	header := make(http.Header)
+	if out.ContentType != nil {
+		header.Set("Content-Type", *out.ContentType)
+	}
+	if out.ETag != nil {
+		header.Set("Etag", *out.ETag)
+	}
+	if out.LastModified != nil {
+		header.Set("Last-Modified", out.LastModified.Format(http.TimeFormat))
+	}
+	if out.ContentLength != nil {
+		header.Set("Content-Length", fmt.Sprintf("%d", *out.ContentLength))
+	}
+
+	return out.Body, header, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproxy
+
+import (
+	"net/http"
+
+	"willnorris.com/go/imageproxy/fetcher"
+)
+
+// fetcherRoundTripper is an http.RoundTripper that dispatches requests whose
+// URL scheme has a registered fetcher.Fetcher to it, and falls back to the
+// wrapped Transport for everything else (plain http/https).  Plugging this
+// in beneath TransformingTransport, as its underlying Transport, lets
+// s3://, gs://, and oci:// sources go
+// through the same transform-on-fetch and httpcache layers that http(s)
+// sources do, rather than bypassing them.
+type fetcherRoundTripper struct {
+	fetchers  *fetcher.Registry
+	transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *fetcherRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.fetchers != nil {
+		if f, ok := t.fetchers.Get(req.URL.Scheme); ok {
+			body, header, err := f.Fetch(req.Context(), req.URL)
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				Status:     "200 OK",
+				StatusCode: http.StatusOK,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     header,
+				Body:       body,
+				Request:    req,
+			}, nil
+		}
+	}
+
+	return t.transport.RoundTrip(req)
+}
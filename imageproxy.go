@@ -20,10 +20,6 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -35,7 +31,11 @@ import (
 	"time"
 
 	"github.com/gregjones/httpcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"willnorris.com/go/imageproxy/fetcher"
 	"willnorris.com/go/imageproxy/routemapping"
+	"willnorris.com/go/imageproxy/signature"
 	tphttp "willnorris.com/go/imageproxy/third_party/http"
 )
 
@@ -58,8 +58,11 @@ type Proxy struct {
 	// absolute.
 	DefaultBaseURL *url.URL
 
-	// SignatureKey is the HMAC key used to verify signed requests.
-	SignatureKey []byte
+	// SignatureKeys is the set of currently-valid HMAC keys used to verify
+	// signed requests.  Supplying more than one key allows operators to
+	// rotate signing secrets without invalidating outstanding URLs signed
+	// with the previous key.
+	SignatureKeys *signature.Keyring
 
 	// Allow images to scale beyond their original dimensions.
 	ScaleUp bool
@@ -71,6 +74,47 @@ type Proxy struct {
 
 	// If true, log additional debug messages
 	Verbose bool
+
+	// MetricsRegistry is the prometheus.Registerer that NewProxy registered
+	// the Proxy's metrics with: a private registry, so that multiple Proxy
+	// instances in the same process don't collide, and the same registry
+	// that is served at /metrics.  The metrics are registered once, in
+	// NewProxy; assigning a different Registerer afterwards does not
+	// register them with it.
+	MetricsRegistry prometheus.Registerer
+
+	// MediaHandlers maps a MIME type, or a MIME type prefix ending in "/",
+	// to the MediaHandler responsible for writing the proxy response for
+	// matching upstream Content-Types.  NewProxy registers
+	// PassthroughMediaHandler for "video/", "audio/", and
+	// "application/pdf"; anything else falls back to the built-in image
+	// handling.  Use RegisterMediaHandler to add more.
+	MediaHandlers map[string]MediaHandler
+
+	// Fetchers selects how source media is retrieved for URL schemes
+	// other than http/https, which continue to go through Client.
+	// NewProxy populates this with the built-in s3://, gs://, and oci://
+	// fetchers; use RegisterFetcher to add more.
+	Fetchers *fetcher.Registry
+
+	metrics  *metrics
+	gatherer prometheus.Gatherer
+
+	// rangeTransport is the same TransformingTransport wired into Client,
+	// but without the httpcache layer wrapping it.  fetch uses it directly
+	// for Range requests, since httpcache keys solely on URL and has no
+	// way to distinguish a cached 206 Partial Content response from a
+	// cached 200 (or vice versa).
+	rangeTransport http.RoundTripper
+}
+
+// RegisterFetcher registers f to retrieve source media whose URL scheme is
+// scheme, replacing any fetcher previously registered for the same scheme.
+func (p *Proxy) RegisterFetcher(scheme string, f fetcher.Fetcher) {
+	if p.Fetchers == nil {
+		p.Fetchers = fetcher.NewRegistry()
+	}
+	p.Fetchers.Register(scheme, f)
 }
 
 var reRouteMapping *routemapping.RouteMapping
@@ -86,24 +130,35 @@ func NewProxy(transport http.RoundTripper, cache Cache, exclusive bool, mappingU
 		cache = NopCache
 	}
 
+	registry := prometheus.NewRegistry()
 	proxy := &Proxy{
-		Cache: cache,
+		Cache:           cache,
+		MetricsRegistry: registry,
+		gatherer:        registry,
 	}
+	proxy.metrics = newMetrics(proxy.MetricsRegistry)
+	proxy.RegisterMediaHandler("video/", PassthroughMediaHandler{})
+	proxy.RegisterMediaHandler("audio/", PassthroughMediaHandler{})
+	proxy.RegisterMediaHandler("application/pdf", PassthroughMediaHandler{})
+	proxy.Fetchers = fetcher.Default()
 
 	client := new(http.Client)
-	client.Transport = &httpcache.Transport{
-		Transport: &TransformingTransport{
-			Transport:     transport,
-			CachingClient: client,
-			log: func(format string, v ...interface{}) {
-				if proxy.Verbose {
-					log.Printf(format, v...)
-				}
-			},
+	transformingTransport := &TransformingTransport{
+		Transport:     &fetcherRoundTripper{fetchers: proxy.Fetchers, transport: transport},
+		CachingClient: client,
+		metrics:       proxy.metrics,
+		log: func(format string, v ...interface{}) {
+			if proxy.Verbose {
+				log.Printf(format, v...)
+			}
 		},
+	}
+	client.Transport = &httpcache.Transport{
+		Transport:           transformingTransport,
 		Cache:               cache,
 		MarkCachedResponses: true,
 	}
+	proxy.rangeTransport = transformingTransport
 
 	flag.Parse()
 
@@ -114,10 +169,7 @@ func NewProxy(transport http.RoundTripper, cache Cache, exclusive bool, mappingU
 	reRouteMapping = routemapping.New(exclusive)
 
 	log.Println("Fetching latest Image Proxy Mapping JSON file. Please wait..")
-	mustFetchRouteMappingChanges(mappingURL)
-
-	var watcherContext context.Context
-	go watchRouteMappingChanges(watcherContext, mappingURL)
+	mustWatchRouteMappingChanges(context.Background(), mappingURL)
 
 	proxy.Client = client
 
@@ -135,60 +187,62 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var h http.Handler = http.HandlerFunc(p.serveImage)
+	if r.URL.Path == "/metrics" {
+		promhttp.HandlerFor(p.gatherer, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+		return
+	}
+
+	var h http.Handler = http.HandlerFunc(p.serveMedia)
 	if p.Timeout > 0 {
 		h = tphttp.TimeoutHandler(h, p.Timeout, "Gateway timeout waiting for remote resource.")
 	}
 	h.ServeHTTP(w, r)
 }
 
-func fetchRouteMappingChanges(watchPath string) (err error) {
-	resp, err := http.Get(watchPath)
-	if nil != err {
-		log.Fatalln(err)
-		return
-	}
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if nil != err {
-		log.Fatalln(err)
-		return
-	}
-	var data map[string]string
-	err = json.Unmarshal(bodyBytes, &data)
-	if nil != err {
+// mustWatchRouteMappingChanges selects a routemapping.MappingSource for
+// mappingURL, blocks until its initial mapping is available, and then
+// applies every subsequent update to reRouteMapping for the lifetime of
+// ctx.
+func mustWatchRouteMappingChanges(ctx context.Context, mappingURL string) {
+	source, err := routemapping.NewSource(mappingURL)
+	if err != nil {
+		log.Println("Fetching the intial JSON Mapping failed..")
 		log.Fatalln(err)
-		return
 	}
-	reRouteMapping.Set(data)
-	return err
-}
 
-func mustFetchRouteMappingChanges(watchPath string) {
-	if err := fetchRouteMappingChanges(watchPath); nil != err {
+	updates, err := source.Watch(ctx)
+	if err != nil {
 		log.Println("Fetching the intial JSON Mapping failed..")
 		log.Fatalln(err)
 	}
-}
 
-func watchRouteMappingChanges(ctx context.Context, watchPath string) {
-	for {
-		time.Sleep(time.Minute)
-		fetchRouteMappingChanges(watchPath)
-	}
+	reRouteMapping.Set(<-updates)
+
+	go func() {
+		for mapping := range updates {
+			reRouteMapping.Set(mapping)
+		}
+	}()
 }
 
 const invalidRequestText = "invalid request URL: %v"
 
-// serveImage handles incoming requests for proxied images.
+// serveImage is a deprecated alias for serveMedia, kept because it's been
+// wired directly into http.HandlerFunc since the early days of this
+// package.
+//
+// Deprecated: use serveMedia.
 func (p *Proxy) serveImage(w http.ResponseWriter, r *http.Request) {
-	var found bool
-	for search, replace := range reRouteMapping.Get() {
-		if strings.Index(r.RequestURI, "/"+search) == 0 {
-			r.RequestURI = strings.Replace(r.RequestURI, "/"+search+"/", "/"+replace+"/", 1)
-			r.URL.Path = strings.Replace(r.URL.Path, "/"+search+"/", "/"+replace+"/", 1)
-			found = true
-			break
-		}
+	p.serveMedia(w, r)
+}
+
+// serveMedia handles incoming requests for proxied media: images, and (via
+// registered MediaHandlers) other types such as video, audio, and PDF.
+func (p *Proxy) serveMedia(w http.ResponseWriter, r *http.Request) {
+	search, replace, found := reRouteMapping.Match(r.URL.Path)
+	if found {
+		r.RequestURI = strings.Replace(r.RequestURI, "/"+search+"/", "/"+replace+"/", 1)
+		r.URL.Path = strings.Replace(r.URL.Path, "/"+search+"/", "/"+replace+"/", 1)
 	}
 
 	if reRouteMapping.IsExclusive() && !found {
@@ -215,7 +269,15 @@ func (p *Proxy) serveImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := p.Client.Get(req.String())
+	routeLabel := search
+	if routeLabel == "" {
+		routeLabel = "none"
+	}
+	p.metrics.requestsByRoute.WithLabelValues(routeLabel).Inc()
+
+	fetchStart := time.Now()
+	resp, err := p.fetch(r, req, posterRequested(r.URL.Path))
+	p.metrics.upstreamLatency.Observe(time.Since(fetchStart).Seconds())
 	if err != nil {
 		msg := fmt.Sprintf("error fetching remote image: %v", err)
 		log.Print(msg)
@@ -225,24 +287,73 @@ func (p *Proxy) serveImage(w http.ResponseWriter, r *http.Request) {
 	defer resp.Body.Close()
 
 	cached := resp.Header.Get(httpcache.XFromCache)
+	if cached == "1" {
+		p.metrics.cacheResults.WithLabelValues("hit").Inc()
+	} else {
+		p.metrics.cacheResults.WithLabelValues("miss").Inc()
+	}
 	if p.Verbose {
 		log.Printf("request: %v (served from cache: %v)", *req, cached == "1")
 	}
 
-	copyHeader(w.Header(), resp.Header, "Cache-Control", "Last-Modified", "Expires", "Etag", "Link")
-
 	if should304(r, resp) {
-		w.WriteHeader(http.StatusNotModified)
-		return
+		p.metrics.notModified.Inc()
 	}
 
-	copyHeader(w.Header(), resp.Header, "Content-Length", "Content-Type")
+	handler := p.mediaHandler(resp.Header.Get("Content-Type"))
+	n, err := handler.Handle(w, r, resp)
+	p.metrics.bytesOut.Add(float64(n))
+	if err != nil {
+		log.Printf("error serving media: %v", err)
+	}
+}
+
+// fetch retrieves the source media for req via p.Client, so that it flows
+// through TransformingTransport (applying any transform requested in the
+// URL fragment) and the shared httpcache layer.  This applies uniformly to
+// plain http/https sources and to schemes served by a registered Fetcher
+// (s3://, gs://, oci://, ...), which fetcherRoundTripper dispatches to
+// beneath TransformingTransport.
+//
+// Range requests bypass p.Client's httpcache layer: httpcache keys solely
+// on URL, so a cached 206 Partial Content response could otherwise be
+// replayed for a later full request, or a cached full response served back
+// for a range request.  They're sent directly through p.rangeTransport
+// instead.  A range request that also asks for a transform or a poster
+// frame still has its full source fetched through TransformingTransport's
+// CachingClient, without the Range header, and gets the whole transformed
+// body back.
+func (p *Proxy) fetch(r *http.Request, req *Request, poster bool) (*http.Response, error) {
+	upstreamReq, err := http.NewRequest(http.MethodGet, req.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if poster {
+		upstreamReq.Header.Set(posterHeaderKey, "1")
+	}
+
+	if rng := r.Header.Get("Range"); rng != "" {
+		upstreamReq.Header.Set("Range", rng)
+		return p.rangeTransport.RoundTrip(upstreamReq)
+	}
 
-	//Enable CORS for 3rd party applications
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	return p.Client.Do(upstreamReq)
+}
 
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+// mediaHandler returns the MediaHandler registered for contentType, falling
+// back to a handler registered under its MIME type prefix (e.g. "video/"),
+// and finally to the built-in image handler.
+func (p *Proxy) mediaHandler(contentType string) MediaHandler {
+	mt := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	if h, ok := p.MediaHandlers[mt]; ok {
+		return h
+	}
+	if i := strings.Index(mt, "/"); i >= 0 {
+		if h, ok := p.MediaHandlers[mt[:i+1]]; ok {
+			return h
+		}
+	}
+	return imageMediaHandler{}
 }
 
 // copyHeader copies header values from src to dst, adding to any existing
@@ -270,7 +381,7 @@ func (p *Proxy) allowed(r *Request) error {
 		return fmt.Errorf("request does not contain an allowed referrer: %v", r)
 	}
 
-	if len(p.Whitelist) == 0 && len(p.SignatureKey) == 0 {
+	if len(p.Whitelist) == 0 && p.SignatureKeys.Empty() {
 		return nil // no whitelist or signature key, all requests accepted
 	}
 
@@ -278,16 +389,38 @@ func (p *Proxy) allowed(r *Request) error {
 		return nil
 	}
 
-	if len(p.SignatureKey) > 0 && validSignature(p.SignatureKey, r) {
-		return nil
+	if !p.SignatureKeys.Empty() {
+		valid, err := validSignature(p.SignatureKeys, r)
+		if err == signature.ErrExpired {
+			return fmt.Errorf("request signature has expired: %v", r)
+		}
+		if valid {
+			return nil
+		}
 	}
 
 	return fmt.Errorf("request does not contain an allowed host or valid signature: %v", r)
 }
 
-// validHost returns whether the host in u matches one of hosts.
+// validHost returns whether u is allowed by hosts.  Entries may be a plain
+// hostname (optionally with a "*." wildcard prefix) to match http/https
+// URLs, or scheme-scoped to match other Fetcher-backed schemes: "s3://"
+// entries match an S3 bucket, "gs://" a GCS bucket, and "oci://" a registry
+// plus repository prefix, e.g. "oci://registry.example.com/team/".  A
+// scheme-scoped entry with no "/" after the scheme must equal the URL's
+// host; one with a "/" matches any URL whose host plus path starts with it.
 func validHost(hosts []string, u *url.URL) bool {
 	for _, host := range hosts {
+		if scheme, rest, ok := splitSchemeHost(host); ok {
+			if u.Scheme == scheme && (u.Host == rest || (strings.Contains(rest, "/") && strings.HasPrefix(u.Host+u.Path, rest))) {
+				return true
+			}
+			continue
+		}
+
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			continue // plain entries only ever match http(s) URLs
+		}
 		if u.Host == host {
 			return true
 		}
@@ -299,6 +432,16 @@ func validHost(hosts []string, u *url.URL) bool {
 	return false
 }
 
+// splitSchemeHost splits a whitelist entry of the form "scheme://rest" into
+// its scheme and remainder.  ok is false for plain hostname entries.
+func splitSchemeHost(entry string) (scheme, rest string, ok bool) {
+	i := strings.Index(entry, "://")
+	if i < 0 {
+		return "", "", false
+	}
+	return entry[:i], entry[i+3:], true
+}
+
 // returns whether the referrer from the request is in the host list.
 func validReferrer(hosts []string, r *http.Request) bool {
 	u, err := url.Parse(r.Header.Get("Referer"))
@@ -309,24 +452,15 @@ func validReferrer(hosts []string, r *http.Request) bool {
 	return validHost(hosts, u)
 }
 
-// validSignature returns whether the request signature is valid.
-func validSignature(key []byte, r *Request) bool {
-	sig := r.Options.Signature
-	if m := len(sig) % 4; m != 0 { // add padding if missing
-		sig += strings.Repeat("=", 4-m)
+// validSignature returns whether the request signature is valid, checking
+// it against every key in the keyring.  It returns signature.ErrExpired if
+// the token parses but its expiration claim has passed.
+func validSignature(keyring *signature.Keyring, r *Request) (bool, error) {
+	valid, err := keyring.Valid(r.URL, r.Options.Signature)
+	if err != nil && err != signature.ErrExpired {
+		log.Printf("error checking signature %q: %v", r.Options.Signature, err)
 	}
-
-	got, err := base64.URLEncoding.DecodeString(sig)
-	if err != nil {
-		log.Printf("error base64 decoding signature %q", r.Options.Signature)
-		return false
-	}
-
-	mac := hmac.New(sha256.New, key)
-	mac.Write([]byte(r.URL.String()))
-	want := mac.Sum(nil)
-
-	return hmac.Equal(got, want)
+	return valid, err
 }
 
 // should304 returns whether we should send a 304 Not Modified in response to
@@ -369,12 +503,14 @@ type TransformingTransport struct {
 	// responses are properly cached.
 	CachingClient *http.Client
 
-	log func(format string, v ...interface{})
+	metrics *metrics
+	log     func(format string, v ...interface{})
 }
 
 // RoundTrip implements the http.RoundTripper interface.
 func (t *TransformingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if req.URL.Fragment == "" {
+	poster := req.Header.Get(posterHeaderKey) == "1"
+	if req.URL.Fragment == "" && !poster {
 		// normal requests pass through
 		if t.log != nil {
 			t.log("fetching remote URL: %v", req.URL)
@@ -399,12 +535,34 @@ func (t *TransformingTransport) RoundTrip(req *http.Request) (*http.Response, er
 	if err != nil {
 		return nil, err
 	}
+	if t.metrics != nil {
+		t.metrics.bytesIn.Add(float64(len(b)))
+	}
 
 	opt := ParseOptions(req.URL.Fragment)
 
-	img, err := Transform(b, opt)
+	transformInput := b
+	posterFrame := false
+	if poster && isVideoContentType(resp.Header.Get("Content-Type")) {
+		frame, ferr := posterExtractor(b)
+		if ferr != nil {
+			log.Printf("error extracting poster frame: %v", ferr)
+		} else {
+			transformInput = frame
+			posterFrame = true
+		}
+	}
+
+	transformStart := time.Now()
+	img, err := Transform(transformInput, opt)
+	if t.metrics != nil {
+		t.metrics.transformLatency.Observe(time.Since(transformStart).Seconds())
+	}
 	if err != nil {
 		log.Printf("error transforming image: %v", err)
+		if t.metrics != nil {
+			t.metrics.transformErrors.WithLabelValues(string(opt.Format)).Inc()
+		}
 		img = b
 	}
 
@@ -414,8 +572,15 @@ func (t *TransformingTransport) RoundTrip(req *http.Request) (*http.Response, er
 	resp.Header.WriteSubset(buf, map[string]bool{
 		"Content-Length": true,
 		// exclude Content-Type header if the format may have changed during transformation
-		"Content-Type": opt.Format != "" || resp.Header.Get("Content-Type") == "image/webp" || resp.Header.Get("Content-Type") == "image/tiff",
+		"Content-Type": posterFrame || opt.Format != "" || resp.Header.Get("Content-Type") == "image/webp" || resp.Header.Get("Content-Type") == "image/tiff",
 	})
+	if posterFrame {
+		// the upstream Content-Type (e.g. video/mp4) no longer describes
+		// the body, which is now the extracted poster image; relabel it so
+		// serveMedia routes the response to the image handler instead of
+		// PassthroughMediaHandler.
+		fmt.Fprintf(buf, "Content-Type: %s\n", posterContentType)
+	}
 	fmt.Fprintf(buf, "Content-Length: %d\n\n", len(img))
 	buf.Write(img)
 
@@ -0,0 +1,110 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyMediaHandler(t *testing.T) {
+	p := new(Proxy)
+	p.RegisterMediaHandler("video/", PassthroughMediaHandler{})
+	p.RegisterMediaHandler("application/pdf", PassthroughMediaHandler{})
+
+	tests := []struct {
+		contentType string
+		wantPass    bool
+	}{
+		{"video/mp4", true},
+		{"video/mp4; charset=binary", true},
+		{"application/pdf", true},
+		{"audio/mpeg", false}, // no handler registered for "audio/" on this Proxy
+		{"image/jpeg", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		_, isPassthrough := p.mediaHandler(tt.contentType).(PassthroughMediaHandler)
+		if isPassthrough != tt.wantPass {
+			t.Errorf("mediaHandler(%q) passthrough = %v, want %v", tt.contentType, isPassthrough, tt.wantPass)
+		}
+	}
+}
+
+func TestPassthroughMediaHandlerRangeRequest(t *testing.T) {
+	body := "partial content bytes"
+	resp := &http.Response{
+		StatusCode: http.StatusPartialContent,
+		Header: http.Header{
+			"Content-Type":  []string{"video/mp4"},
+			"Content-Range": []string{"bytes 10-31/1000"},
+			"Accept-Ranges": []string{"bytes"},
+		},
+		Body: ioutil.NopCloser(bytes.NewBufferString(body)),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/video/http://example.com/video.mp4", nil)
+	req.Header.Set("Range", "bytes=10-31")
+	w := httptest.NewRecorder()
+
+	n, err := (PassthroughMediaHandler{}).Handle(w, req, resp)
+	if err != nil {
+		t.Fatalf("Handle() returned error: %v", err)
+	}
+	if n != int64(len(body)) {
+		t.Errorf("Handle() returned n = %d, want %d", n, len(body))
+	}
+
+	result := w.Result()
+	if result.StatusCode != http.StatusPartialContent {
+		t.Errorf("status = %d, want %d", result.StatusCode, http.StatusPartialContent)
+	}
+	if got := result.Header.Get("Content-Range"); got != "bytes 10-31/1000" {
+		t.Errorf("Content-Range = %q, want %q", got, "bytes 10-31/1000")
+	}
+	if got := w.Body.String(); got != body {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+}
+
+func TestPassthroughMediaHandlerFullRequest(t *testing.T) {
+	body := "full content bytes"
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type": []string{"audio/mpeg"},
+		},
+		Body: ioutil.NopCloser(bytes.NewBufferString(body)),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/audio/http://example.com/audio.mp3", nil)
+	w := httptest.NewRecorder()
+
+	if _, err := (PassthroughMediaHandler{}).Handle(w, req, resp); err != nil {
+		t.Fatalf("Handle() returned error: %v", err)
+	}
+
+	result := w.Result()
+	if result.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", result.StatusCode, http.StatusOK)
+	}
+	if got := w.Body.String(); got != body {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+}
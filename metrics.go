@@ -0,0 +1,91 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics holds the Prometheus collectors reported by a Proxy's /metrics
+// endpoint.
+type metrics struct {
+	requestsByRoute  *prometheus.CounterVec
+	cacheResults     *prometheus.CounterVec
+	upstreamLatency  prometheus.Histogram
+	transformLatency prometheus.Histogram
+	transformErrors  *prometheus.CounterVec
+	bytesIn          prometheus.Counter
+	bytesOut         prometheus.Counter
+	notModified      prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		requestsByRoute: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "imageproxy",
+			Name:      "requests_total",
+			Help:      "Number of requests served, by route-mapping key.",
+		}, []string{"route"}),
+		cacheResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "imageproxy",
+			Name:      "cache_results_total",
+			Help:      "Number of upstream fetches, by whether they were served from cache.",
+		}, []string{"result"}),
+		upstreamLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "imageproxy",
+			Name:      "upstream_fetch_duration_seconds",
+			Help:      "Latency of fetching the source media through the proxy's client, measured around the whole round trip, so it includes cache lookups and any transform applied.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		transformLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "imageproxy",
+			Name:      "transform_duration_seconds",
+			Help:      "Latency of transforming a fetched image.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		transformErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "imageproxy",
+			Name:      "transform_errors_total",
+			Help:      "Number of transform errors, by the requested output format (empty when none was requested).",
+		}, []string{"format"}),
+		bytesIn: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "imageproxy",
+			Name:      "upstream_bytes_total",
+			Help:      "Total bytes of source media read for transform and poster requests, whether from upstream hosts or the cache; pass-through requests are not counted.",
+		}),
+		bytesOut: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "imageproxy",
+			Name:      "response_bytes_total",
+			Help:      "Total bytes written in proxy responses.",
+		}),
+		notModified: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "imageproxy",
+			Name:      "not_modified_total",
+			Help:      "Number of requests short-circuited with a 304 Not Modified response.",
+		}),
+	}
+
+	reg.MustRegister(
+		m.requestsByRoute,
+		m.cacheResults,
+		m.upstreamLatency,
+		m.transformLatency,
+		m.transformErrors,
+		m.bytesIn,
+		m.bytesOut,
+		m.notModified,
+	)
+	return m
+}
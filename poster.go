@@ -0,0 +1,105 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// posterHeaderKey is set by Proxy.fetch on the upstream request to signal a
+// poster frame was requested.  It never reaches an actual origin server: the
+// poster option isn't a recognized Options field, so it can't be carried
+// through req.String()'s Options.String() encoding the way other transform
+// options are; TransformingTransport reads it directly instead.
+const posterHeaderKey = "X-Imageproxy-Poster"
+
+// posterContentType is the MIME type of the image produced by
+// extractPosterFrame.
+const posterContentType = "image/jpeg"
+
+// isVideoContentType reports whether contentType is a video MIME type that
+// poster frame extraction supports.
+func isVideoContentType(contentType string) bool {
+	mt := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return strings.HasPrefix(mt, "video/")
+}
+
+// hasPosterOption reports whether fragment, a comma-separated option list,
+// includes the "poster" option requesting a still frame be extracted from a
+// video instead of the video itself.
+func hasPosterOption(fragment string) bool {
+	for _, opt := range strings.Split(fragment, ",") {
+		if opt == "poster" {
+			return true
+		}
+	}
+	return false
+}
+
+// posterRequested reports whether the options segment of an inbound proxy
+// request path ("/{options}/{remote-url}") includes the "poster" option.
+// It's checked against the raw request path rather than req.Options, since
+// poster isn't a recognized Options field.
+func posterRequested(path string) bool {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[:i]
+	}
+	return hasPosterOption(path)
+}
+
+// posterExtractor implements poster frame extraction; it's a package-level
+// var, rather than TransformingTransport calling extractPosterFrame
+// directly, so tests can stub out the ffmpeg dependency.
+var posterExtractor = extractPosterFrame
+
+// extractPosterFrame decodes the first frame of the video in data using
+// ffmpeg, returning it as JPEG-encoded image bytes suitable for feeding into
+// Transform.
+func extractPosterFrame(data []byte) ([]byte, error) {
+	in, err := ioutil.TempFile("", "imageproxy-poster-in-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in.Name())
+	defer in.Close()
+
+	if _, err := in.Write(data); err != nil {
+		return nil, err
+	}
+	if err := in.Close(); err != nil {
+		return nil, err
+	}
+
+	outPath := in.Name() + ".jpg"
+	defer os.Remove(outPath)
+
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-i", in.Name(),
+		"-frames:v", "1",
+		"-f", "image2",
+		outPath,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg poster extraction failed: %v: %s", err, out)
+	}
+
+	return ioutil.ReadFile(outPath)
+}
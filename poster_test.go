@@ -0,0 +1,153 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPosterRequested(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/poster/http://example.com/video.mp4", true},
+		{"/100x100,poster/http://example.com/video.mp4", true},
+		{"poster/http://example.com/video.mp4", true},
+		{"/100x100/http://example.com/video.mp4", false},
+		{"/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := posterRequested(tt.path); got != tt.want {
+			t.Errorf("posterRequested(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsVideoContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"video/mp4", true},
+		{"video/mp4; charset=binary", true},
+		{"image/jpeg", false},
+		{"application/pdf", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isVideoContentType(tt.contentType); got != tt.want {
+			t.Errorf("isVideoContentType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// fakeRoundTripper returns resp for every request, ignoring req entirely.
+type fakeRoundTripper struct {
+	resp *http.Response
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := *f.resp
+	r.Body = ioutil.NopCloser(bytes.NewReader(f.body()))
+	return &r, nil
+}
+
+func (f *fakeRoundTripper) body() []byte {
+	b, _ := ioutil.ReadAll(f.resp.Body)
+	f.resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b
+}
+
+// TestTransformingTransportPosterFrame verifies that a request carrying the
+// internal poster header is served as the extracted poster frame, labeled
+// with the poster's own Content-Type rather than the upstream video's, even
+// though the request carries no URL fragment (and so would otherwise take
+// the pass-through branch untouched).
+func TestTransformingTransportPosterFrame(t *testing.T) {
+	videoResp := httptest.NewRecorder()
+	videoResp.Header().Set("Content-Type", "video/mp4")
+	videoResp.WriteHeader(http.StatusOK)
+	videoResp.Body = bytes.NewBufferString("fake video bytes")
+
+	orig := posterExtractor
+	posterExtractor = func(data []byte) ([]byte, error) {
+		return []byte("fake jpeg bytes"), nil
+	}
+	defer func() { posterExtractor = orig }()
+
+	cachingClient := &http.Client{Transport: &fakeRoundTripper{resp: videoResp.Result()}}
+	tt := &TransformingTransport{
+		Transport:     &fakeRoundTripper{resp: videoResp.Result()},
+		CachingClient: cachingClient,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/video.mp4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(posterHeaderKey, "1")
+
+	resp, err := tt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != posterContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, posterContentType)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "fake jpeg bytes" {
+		t.Errorf("body = %q, want %q", body, "fake jpeg bytes")
+	}
+}
+
+// TestTransformingTransportNoPoster verifies that a plain, fragment-less
+// request still passes straight through, unaffected by the poster check.
+func TestTransformingTransportNoPoster(t *testing.T) {
+	imgResp := httptest.NewRecorder()
+	imgResp.Header().Set("Content-Type", "image/jpeg")
+	imgResp.WriteHeader(http.StatusOK)
+	imgResp.Body = bytes.NewBufferString("image bytes")
+
+	tt := &TransformingTransport{
+		Transport: &fakeRoundTripper{resp: imgResp.Result()},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/image.jpg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := tt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want %q", ct, "image/jpeg")
+	}
+}
@@ -0,0 +1,81 @@
+package routemapping
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileSource is a MappingSource that reads a mapping JSON document from the
+// local filesystem, re-reading it whenever the file is written.
+type FileSource struct {
+	// Path is the location of the mapping JSON document on disk.
+	Path string
+}
+
+// Watch implements MappingSource.
+func (s *FileSource) Watch(ctx context.Context) (<-chan map[string]string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(s.Path); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	ch := make(chan map[string]string, 1)
+
+	mapping, err := s.read()
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	ch <- mapping
+
+	go func() {
+		defer close(ch)
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				m, err := s.read()
+				if err != nil {
+					continue // transient read error (e.g. editor doing a rename-swap); try again next event
+				}
+				select {
+				case ch <- m:
+				case <-ctx.Done():
+					return
+				}
+			case <-watcher.Errors:
+				continue
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (s *FileSource) read() (map[string]string, error) {
+	body, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(body, &mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
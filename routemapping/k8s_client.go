@@ -0,0 +1,16 @@
+package routemapping
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// inClusterClient builds a Kubernetes client using the in-cluster service
+// account config, for newConfigMapSourceFromURL.
+func inClusterClient() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
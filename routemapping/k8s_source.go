@@ -0,0 +1,113 @@
+package routemapping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// configMapSyncTimeout bounds how long Watch waits for the informer's
+// initial cache sync before giving up.
+const configMapSyncTimeout = 10 * time.Second
+
+// ConfigMapSource is a MappingSource backed by a Kubernetes ConfigMap,
+// watched via a shared informer rather than polling the API server.  The
+// mapping is read from DataKey within the ConfigMap, whose value must be a
+// "search" -> "replace" JSON document; other keys in the ConfigMap's Data
+// are ignored.
+type ConfigMapSource struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+
+	// DataKey is the key within the ConfigMap's Data holding the mapping
+	// JSON document.  Defaults to "mapping.json" if empty.
+	DataKey string
+}
+
+// Watch implements MappingSource.
+func (s *ConfigMapSource) Watch(ctx context.Context) (<-chan map[string]string, error) {
+	dataKey := s.DataKey
+	if dataKey == "" {
+		dataKey = "mapping.json"
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(s.Client, 0,
+		informers.WithNamespace(s.Namespace))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	ch := make(chan map[string]string, 1)
+
+	send := func(obj interface{}) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok || cm.Name != s.Name {
+			return
+		}
+		mapping, err := parseConfigMapMapping(cm, dataKey)
+		if err != nil {
+			return // malformed update; keep serving the last good mapping
+		}
+		select {
+		case ch <- mapping:
+		case <-ctx.Done():
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: send,
+		UpdateFunc: func(_, newObj interface{}) {
+			send(newObj)
+		},
+	})
+
+	go informer.Run(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, configMapSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		return nil, fmt.Errorf("routemapping: timed out waiting for ConfigMap %s/%s informer cache sync", s.Namespace, s.Name)
+	}
+
+	// Deliver the initial state synchronously, the same contract HTTPSource
+	// and FileSource provide, rather than relying solely on AddFunc (which
+	// never fires, leaving Watch's caller blocked forever, if the named
+	// ConfigMap doesn't exist yet).
+	initial := map[string]string{}
+	if obj, exists, err := informer.GetStore().GetByKey(s.Namespace + "/" + s.Name); err == nil && exists {
+		if cm, ok := obj.(*corev1.ConfigMap); ok {
+			if mapping, err := parseConfigMapMapping(cm, dataKey); err == nil {
+				initial = mapping
+			}
+		}
+	}
+	select {
+	case ch <- initial:
+	default:
+		// AddFunc has already buffered the ConfigMap's current state.
+	}
+
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func parseConfigMapMapping(cm *corev1.ConfigMap, dataKey string) (map[string]string, error) {
+	data, ok := cm.Data[dataKey]
+	if !ok {
+		return map[string]string{}, nil
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(data), &mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
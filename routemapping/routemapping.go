@@ -2,40 +2,92 @@ package routemapping
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // RouteMapping contains an internal mapping
 type RouteMapping struct {
-	mapping   map[string]string
-	mutex     *sync.RWMutex
-	exclusive bool // Exclusive will force only the mapping to be valid and as the super-mask
+	value     atomic.Value // holds *compiledMapping
+	exclusive bool         // Exclusive will force only the mapping to be valid and as the super-mask
+
+	subMu       sync.Mutex
+	subscribers []chan map[string]string
+}
+
+// compiledMapping bundles the raw mapping together with the trie compiled
+// from it, so that Get and Match always observe a consistent pair.
+type compiledMapping struct {
+	mapping map[string]string
+	trie    *trieNode
 }
 
 // New returns a new instance of RouteMapping.
 func New(exclusive bool) *RouteMapping {
-	return &RouteMapping{
-		mapping:   map[string]string{},
-		mutex:     &sync.RWMutex{},
+	m := &RouteMapping{
 		exclusive: exclusive,
 	}
+	m.value.Store(&compiledMapping{mapping: map[string]string{}, trie: buildTrie(nil)})
+	return m
 }
 
-// Set sets a new set of mappings.
+// Set sets a new set of mappings, atomically replacing any previous
+// mapping, recompiling the prefix trie used by Match, and notifying any
+// subscribers of the change.
 func (m *RouteMapping) Set(mapping map[string]string) {
-	m.mutex.Lock()
-	m.mapping = mapping
-	m.mutex.Unlock()
+	m.value.Store(&compiledMapping{mapping: mapping, trie: buildTrie(mapping)})
+
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- mapping:
+		default:
+			// drop the update for slow subscribers rather than block Set
+		}
+	}
 }
 
-// Get gets all iternal mappings.
+// Get returns an immutable snapshot of the current mappings.  Because Set
+// always replaces the map wholesale rather than mutating it in place, the
+// returned map is safe to range over even while a concurrent Set call is in
+// progress.
 func (m *RouteMapping) Get() map[string]string {
-	m.mutex.RLock()
-	mapping := m.mapping
-	m.mutex.RUnlock()
-	return mapping
+	return m.value.Load().(*compiledMapping).mapping
+}
+
+// Match returns the longest registered prefix matching path along with its
+// replacement, running in O(len(path)) regardless of how many prefixes are
+// registered.  ok is false if no prefix matches.
+func (m *RouteMapping) Match(path string) (search, replace string, ok bool) {
+	return m.value.Load().(*compiledMapping).trie.match(path)
 }
 
 // IsExclusive indicates if exclusive is true.
 func (m *RouteMapping) IsExclusive() bool {
 	return m.exclusive
 }
+
+// Subscribe returns a channel that receives the new mapping snapshot every
+// time Set is called.  The channel is closed when unsubscribe is called.
+// Subscribers that fail to keep up with updates will miss intermediate
+// values; they always have the option of calling Get for the latest state.
+func (m *RouteMapping) Subscribe() (ch <-chan map[string]string, unsubscribe func()) {
+	c := make(chan map[string]string, 1)
+
+	m.subMu.Lock()
+	m.subscribers = append(m.subscribers, c)
+	m.subMu.Unlock()
+
+	unsubscribe = func() {
+		m.subMu.Lock()
+		defer m.subMu.Unlock()
+		for i, sub := range m.subscribers {
+			if sub == c {
+				m.subscribers = append(m.subscribers[:i], m.subscribers[i+1:]...)
+				close(c)
+				break
+			}
+		}
+	}
+	return c, unsubscribe
+}
@@ -0,0 +1,42 @@
+package routemapping
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NewSource returns the MappingSource appropriate for mappingURL, selected
+// by URL scheme:
+//
+//	http://, https://  -> HTTPSource
+//	file://, (no scheme) -> FileSource
+//	k8s://<namespace>/<configmap-name> -> ConfigMapSource, using the
+//	    in-cluster config for the Kubernetes client
+//
+// k8s:// sources require building with the kubernetes in-cluster config
+// available; use ConfigMapSource directly to supply a different client.
+func NewSource(mappingURL string) (MappingSource, error) {
+	switch {
+	case strings.HasPrefix(mappingURL, "http://"), strings.HasPrefix(mappingURL, "https://"):
+		return &HTTPSource{URL: mappingURL}, nil
+	case strings.HasPrefix(mappingURL, "file://"):
+		return &FileSource{Path: strings.TrimPrefix(mappingURL, "file://")}, nil
+	case strings.HasPrefix(mappingURL, "k8s://"):
+		return newConfigMapSourceFromURL(strings.TrimPrefix(mappingURL, "k8s://"))
+	default:
+		return &FileSource{Path: mappingURL}, nil
+	}
+}
+
+func newConfigMapSourceFromURL(path string) (MappingSource, error) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("routemapping: invalid k8s mapping URL, want k8s://<namespace>/<configmap-name>, got %q", path)
+	}
+
+	client, err := inClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigMapSource{Client: client, Namespace: parts[0], Name: parts[1]}, nil
+}
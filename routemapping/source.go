@@ -0,0 +1,127 @@
+package routemapping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// MappingSource produces a stream of route mapping updates.  Implementations
+// decide when the underlying mapping has changed: HTTPSource sends on every
+// 200 response to its conditional request, FileSource on every write to the
+// file, and ConfigMapSource on every update to the ConfigMap, so an update
+// may carry the same mapping as the one before it.
+type MappingSource interface {
+	// Watch begins watching for changes and returns a channel of mapping
+	// updates.  The first update, representing the initial state, is sent
+	// as soon as it is available.  The channel is closed when ctx is
+	// done or an unrecoverable error occurs.
+	Watch(ctx context.Context) (<-chan map[string]string, error)
+}
+
+// HTTPSource is a MappingSource that polls a URL serving a JSON mapping
+// document, using ETag/If-Modified-Since conditional requests (the same
+// mechanism used to avoid re-fetching unchanged images) to avoid
+// re-downloading and re-parsing the document when it hasn't changed.
+type HTTPSource struct {
+	// URL is the location of the mapping JSON document.
+	URL string
+
+	// Client is used to make requests.  If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// PollInterval is how often to check for updates.  Defaults to one
+	// minute if zero.
+	PollInterval time.Duration
+}
+
+// Watch implements MappingSource.
+func (s *HTTPSource) Watch(ctx context.Context) (<-chan map[string]string, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	interval := s.PollInterval
+	if interval == 0 {
+		interval = time.Minute
+	}
+
+	ch := make(chan map[string]string, 1)
+
+	mapping, etag, lastMod, err := s.fetch(client, "", "")
+	if err != nil {
+		return nil, err
+	}
+	ch <- mapping
+
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m, e, lm, err := s.fetch(client, etag, lastMod)
+				if err != nil {
+					continue // transient fetch error; try again next tick
+				}
+				if m == nil {
+					continue // 304 Not Modified, nothing changed
+				}
+				etag, lastMod = e, lm
+				select {
+				case ch <- m:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// fetch retrieves the mapping document, returning (nil, etag, lastMod, nil)
+// if the server responds 304 Not Modified.
+func (s *HTTPSource) fetch(client *http.Client, etag, lastMod string) (map[string]string, string, string, error) {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastMod != "" {
+		req.Header.Set("If-Modified-Since", lastMod)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, lastMod, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("routemapping: unexpected status fetching %s: %s", s.URL, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", err
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(body, &mapping); err != nil {
+		return nil, "", "", err
+	}
+
+	return mapping, resp.Header.Get("Etag"), resp.Header.Get("Last-Modified"), nil
+}
@@ -0,0 +1,59 @@
+package routemapping
+
+// trieNode is a node in the byte-trie built over route mapping prefixes.
+// Matching a path is O(len(path)) regardless of how many prefixes are
+// registered, and the longest registered prefix along the path always wins,
+// which gives deterministic behavior when prefixes overlap (e.g. "img/" and
+// "img/thumbs/").
+type trieNode struct {
+	children map[byte]*trieNode
+
+	// isEnd and search/replace are only set on nodes that terminate a
+	// registered prefix.
+	isEnd   bool
+	search  string
+	replace string
+}
+
+// buildTrie compiles mapping into a trie keyed on "/"+search+"/", matching
+// the prefix that serveImage has always matched requests against.
+func buildTrie(mapping map[string]string) *trieNode {
+	root := &trieNode{children: map[byte]*trieNode{}}
+	for search, replace := range mapping {
+		root.insert("/"+search+"/", search, replace)
+	}
+	return root
+}
+
+func (n *trieNode) insert(key, search, replace string) {
+	node := n
+	for i := 0; i < len(key); i++ {
+		b := key[i]
+		child, ok := node.children[b]
+		if !ok {
+			child = &trieNode{children: map[byte]*trieNode{}}
+			node.children[b] = child
+		}
+		node = child
+	}
+	node.isEnd = true
+	node.search = search
+	node.replace = replace
+}
+
+// match walks path, returning the search/replace pair of the longest
+// registered prefix that matches, and whether any prefix matched at all.
+func (n *trieNode) match(path string) (search, replace string, ok bool) {
+	node := n
+	for i := 0; i < len(path); i++ {
+		child, exists := node.children[path[i]]
+		if !exists {
+			break
+		}
+		node = child
+		if node.isEnd {
+			search, replace, ok = node.search, node.replace, true
+		}
+	}
+	return search, replace, ok
+}
@@ -0,0 +1,87 @@
+package routemapping
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMatchLongestPrefix(t *testing.T) {
+	rm := New(false)
+	rm.Set(map[string]string{
+		"img":        "origin-a",
+		"img/thumbs": "origin-b",
+	})
+
+	tests := []struct {
+		path        string
+		wantSearch  string
+		wantReplace string
+		wantOK      bool
+	}{
+		{"/img/foo.jpg", "img", "origin-a", true},
+		{"/img/thumbs/foo.jpg", "img/thumbs", "origin-b", true},
+		{"/other/foo.jpg", "", "", false},
+		{"/img", "", "", false}, // no trailing slash, no match
+	}
+
+	for _, tt := range tests {
+		search, replace, ok := rm.Match(tt.path)
+		if ok != tt.wantOK || search != tt.wantSearch || replace != tt.wantReplace {
+			t.Errorf("Match(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.path, search, replace, ok, tt.wantSearch, tt.wantReplace, tt.wantOK)
+		}
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	mapping := make(map[string]string, 10000)
+	for i := 0; i < 10000; i++ {
+		mapping[fmt.Sprintf("route%d", i)] = fmt.Sprintf("origin%d", i)
+	}
+	rm := New(false)
+	rm.Set(mapping)
+
+	path := "/route9999/some/deep/path/to/an/image.jpg"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rm.Match(path)
+	}
+}
+
+// FuzzMatch exercises Match with overlapping prefixes, trailing slashes,
+// and URL-escaped path segments, checking that it never panics and that a
+// match (when found) is always a true prefix of the queried path.
+func FuzzMatch(f *testing.F) {
+	rm := New(false)
+	rm.Set(map[string]string{
+		"img":              "origin-a",
+		"img/thumbs":       "origin-b",
+		"img/thumbs/large": "origin-c",
+		"a b":              "origin-d",
+	})
+
+	f.Add("/img/foo.jpg")
+	f.Add("/img/thumbs/foo.jpg")
+	f.Add("/img/thumbs/large/foo.jpg")
+	f.Add("/img//thumbs/foo.jpg")
+	f.Add("/a%20b/foo.jpg")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, path string) {
+		unescaped, err := url.PathUnescape(path)
+		if err != nil {
+			unescaped = path
+		}
+
+		search, _, ok := rm.Match(unescaped)
+		if ok && search == "" {
+			t.Errorf("Match(%q) reported ok with empty search", unescaped)
+		}
+		if ok && !strings.HasPrefix(unescaped, "/"+search+"/") {
+			t.Errorf("Match(%q) = %q, which is not a prefix of the path", unescaped, search)
+		}
+	})
+}
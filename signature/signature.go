@@ -0,0 +1,167 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signature implements signing and verification of imageproxy
+// request URLs, including optional expiration and support for verifying
+// against a rotating set of HMAC keys.
+package signature
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyring holds the set of HMAC keys that are currently valid for verifying
+// signatures.  Operators can rotate signing secrets by adding a new key to
+// the keyring while leaving old keys in place until outstanding signed URLs
+// using them have expired.
+type Keyring struct {
+	keys [][]byte
+}
+
+// NewKeyring returns a Keyring containing keys.
+func NewKeyring(keys ...[]byte) *Keyring {
+	return &Keyring{keys: keys}
+}
+
+// LoadKeyringFile reads a keyring from path.  The file is expected to
+// contain one base64 or raw key per line; blank lines and lines beginning
+// with "#" are ignored.
+func LoadKeyringFile(path string) (*Keyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, err := base64.StdEncoding.DecodeString(line); err == nil {
+			keys = append(keys, key)
+			continue
+		}
+		keys = append(keys, []byte(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("signature: no keys found in keyring file %q", path)
+	}
+	return NewKeyring(keys...), nil
+}
+
+// Empty returns whether the keyring contains no keys.
+func (k *Keyring) Empty() bool {
+	return k == nil || len(k.keys) == 0
+}
+
+// claim returns the bytes that are signed for u, optionally including an
+// exp=<unix> claim.
+func claim(u *url.URL, exp time.Time) []byte {
+	s := u.String()
+	if !exp.IsZero() {
+		s = fmt.Sprintf("%s?exp=%d", s, exp.Unix())
+	}
+	return []byte(s)
+}
+
+// Sign returns the URL-safe base64 signature token for u, valid until exp
+// (or forever, if exp is the zero value), using the given key.  The
+// returned token encodes both the HMAC and the expiration claim, and is the
+// value that should be supplied as the "s" (signature) option on the
+// request.
+func Sign(key []byte, u *url.URL, exp time.Time) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(claim(u, exp))
+	sig := base64.URLEncoding.EncodeToString(mac.Sum(nil))
+
+	if exp.IsZero() {
+		return sig
+	}
+	return fmt.Sprintf("%s.%d", sig, exp.Unix())
+}
+
+// ErrExpired is returned by Valid when the token's expiration claim has
+// passed.
+var ErrExpired = errors.New("signature: request signature has expired")
+
+// Valid reports whether token is a valid signature for u under any key in
+// the keyring, and has not expired.  It returns ErrExpired specifically so
+// that callers can distinguish an expired signature from an invalid one.
+func (k *Keyring) Valid(u *url.URL, token string) (bool, error) {
+	if k.Empty() {
+		return false, nil
+	}
+
+	sig, exp, err := splitToken(token)
+	if err != nil {
+		return false, err
+	}
+	if !exp.IsZero() && time.Now().After(exp) {
+		return false, ErrExpired
+	}
+
+	got, err := decodeSig(sig)
+	if err != nil {
+		return false, err
+	}
+
+	want := claim(u, exp)
+	for _, key := range k.keys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(want)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// splitToken splits a token produced by Sign into its signature and
+// expiration claim, if any.
+func splitToken(token string) (sig string, exp time.Time, err error) {
+	parts := strings.SplitN(token, ".", 2)
+	sig = parts[0]
+	if len(parts) == 1 {
+		return sig, time.Time{}, nil
+	}
+
+	sec, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("signature: invalid expiration claim %q: %v", parts[1], err)
+	}
+	return sig, time.Unix(sec, 0), nil
+}
+
+func decodeSig(sig string) ([]byte, error) {
+	if m := len(sig) % 4; m != 0 { // add padding if missing
+		sig += strings.Repeat("=", 4-m)
+	}
+	return base64.URLEncoding.DecodeString(sig)
+}
@@ -0,0 +1,147 @@
+// Copyright 2013 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", s, err)
+	}
+	return u
+}
+
+func TestSignAndValidRoundTrip(t *testing.T) {
+	key := []byte("secret-key")
+	u := mustParseURL(t, "https://example.com/100x100/http://host/image.jpg")
+
+	tok := Sign(key, u, time.Time{})
+	keyring := NewKeyring(key)
+
+	valid, err := keyring.Valid(u, tok)
+	if err != nil {
+		t.Fatalf("Valid() returned error: %v", err)
+	}
+	if !valid {
+		t.Errorf("Valid() = false, want true for a freshly signed, non-expiring token")
+	}
+}
+
+func TestValidRejectsTamperedURL(t *testing.T) {
+	key := []byte("secret-key")
+	u := mustParseURL(t, "https://example.com/100x100/http://host/image.jpg")
+	tok := Sign(key, u, time.Time{})
+
+	keyring := NewKeyring(key)
+	other := mustParseURL(t, "https://example.com/200x200/http://host/image.jpg")
+
+	valid, err := keyring.Valid(other, tok)
+	if err != nil {
+		t.Fatalf("Valid() returned error: %v", err)
+	}
+	if valid {
+		t.Errorf("Valid() = true for a token signed against a different URL, want false")
+	}
+}
+
+func TestValidExpiry(t *testing.T) {
+	key := []byte("secret-key")
+	u := mustParseURL(t, "https://example.com/100x100/http://host/image.jpg")
+
+	future := Sign(key, u, time.Now().Add(time.Hour))
+	past := Sign(key, u, time.Now().Add(-time.Hour))
+
+	keyring := NewKeyring(key)
+
+	if valid, err := keyring.Valid(u, future); err != nil || !valid {
+		t.Errorf("Valid() for unexpired token = (%v, %v), want (true, nil)", valid, err)
+	}
+
+	valid, err := keyring.Valid(u, past)
+	if err != ErrExpired {
+		t.Errorf("Valid() for expired token returned err = %v, want ErrExpired", err)
+	}
+	if valid {
+		t.Errorf("Valid() for expired token = true, want false")
+	}
+}
+
+func TestValidTamperedExpiry(t *testing.T) {
+	key := []byte("secret-key")
+	u := mustParseURL(t, "https://example.com/100x100/http://host/image.jpg")
+
+	// Sign a token that expired an hour ago, then rewrite its exp claim to
+	// the future: the HMAC was computed over the original claim, so it must
+	// not validate against the tampered one.
+	tok := Sign(key, u, time.Now().Add(-time.Hour))
+	sig := strings.SplitN(tok, ".", 2)[0]
+	tampered := sig + "." + "9999999999"
+
+	keyring := NewKeyring(key)
+	valid, err := keyring.Valid(u, tampered)
+	if valid {
+		t.Errorf("Valid() = true for a token with a tampered exp claim, want false (err=%v)", err)
+	}
+}
+
+func TestValidKeyRotation(t *testing.T) {
+	oldKey := []byte("old-key")
+	newKey := []byte("new-key")
+	u := mustParseURL(t, "https://example.com/100x100/http://host/image.jpg")
+
+	tok := Sign(oldKey, u, time.Time{})
+
+	// A keyring containing only the new key must reject a token signed
+	// with the old one...
+	newOnly := NewKeyring(newKey)
+	if valid, _ := newOnly.Valid(u, tok); valid {
+		t.Errorf("Valid() = true against a keyring that doesn't contain the signing key")
+	}
+
+	// ...but once both keys are in rotation, outstanding URLs signed with
+	// the old key must keep validating.
+	both := NewKeyring(newKey, oldKey)
+	valid, err := both.Valid(u, tok)
+	if err != nil {
+		t.Fatalf("Valid() returned error: %v", err)
+	}
+	if !valid {
+		t.Errorf("Valid() = false for a token signed with a still-rotated-in key")
+	}
+}
+
+func TestEmptyKeyring(t *testing.T) {
+	var k *Keyring
+	if !k.Empty() {
+		t.Errorf("nil Keyring.Empty() = false, want true")
+	}
+
+	k = NewKeyring()
+	if !k.Empty() {
+		t.Errorf("Keyring with no keys Empty() = false, want true")
+	}
+
+	k = NewKeyring([]byte("key"))
+	if k.Empty() {
+		t.Errorf("Keyring with a key Empty() = true, want false")
+	}
+}